@@ -5,13 +5,14 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/Osselnet/metrics-collector/internal/crashreporter"
 	"github.com/Osselnet/metrics-collector/internal/storage"
 	"github.com/Osselnet/metrics-collector/pkg/metrics"
 	"github.com/go-resty/resty/v2"
 	"github.com/shirou/gopsutil/cpu"
 	"github.com/shirou/gopsutil/mem"
-	"log"
 	"math/rand"
 	"net/http"
 	"os"
@@ -24,32 +25,57 @@ import (
 )
 
 type Config struct {
-	Timeout        time.Duration
-	PollInterval   time.Duration
-	ReportInterval time.Duration
-	Address        string
-	Key            string
-	RateLimit      int
+	Timeout         time.Duration
+	PollInterval    time.Duration
+	ReportInterval  time.Duration
+	Address         string
+	Key             string
+	RateLimit       int
+	TransportKind   TransportKind
+	Job             string
+	RemoteWriteURL  string
+	ExternalLabels  map[string]string
+	BaseLabels      map[string]string // e.g. host, env, service; stamped on every metric this agent sends
+	LogLevel        string            // debug/info/warn/error, defaults to info
+	Alias           string            // prefixes every log line, for telling multiple agents apart
+	CrashReportURL  string            // where panic reports are POSTed; empty disables crash reporting
+	CrashSpoolDir   string            // directory for reports that failed to send, retried on next start
+	ShutdownTimeout time.Duration     // max time to wait for producers to stop before closing metricsCh
 }
 
+const logTailSize = 50
+
+// defaultShutdownTimeout is used when Config.ShutdownTimeout is unset, so
+// existing callers get a real grace period instead of racing producers on
+// every shutdown.
+const defaultShutdownTimeout = 5 * time.Second
+
 type Agent struct {
 	*metrics.Metrics
-	storage storage.Repositories
-	client  *resty.Client
+	cfg           Config
+	storage       storage.Repositories
+	client        *resty.Client
+	transport     Transport
+	logger        Logger
+	crashReporter *crashreporter.Reporter
+
+	// cumulativeCounters tracks the running remote_write total per metric
+	// ID across reports; see accumulateCounter in remotewrite.go.
+	cumulativeCountersMu sync.Mutex
+	cumulativeCounters   map[string]int64
 }
 
 type Metrics struct {
-	ID    string          `json:"id"`             // имя метрики
-	MType string          `json:"type"`           // параметр, принимающий значение gauge или counter
-	Delta metrics.Counter `json:"delta"`          // значение метрики в случае передачи counter
-	Value metrics.Gauge   `json:"value"`          // значение метрики в случае передачи gauge
-	Hash  string          `json:"hash,omitempty"` // значение хеш-функции
+	ID     string            `json:"id"`               // имя метрики
+	MType  string            `json:"type"`             // параметр, принимающий значение gauge или counter
+	Delta  metrics.Counter   `json:"delta"`            // значение метрики в случае передачи counter
+	Value  metrics.Gauge     `json:"value"`            // значение метрики в случае передачи gauge
+	Hash   string            `json:"hash,omitempty"`   // значение хеш-функции
+	Labels map[string]string `json:"labels,omitempty"` // метки экземпляра (host, env, service, ...)
 }
 
 type Sender func(context.Context, <-chan metrics.Metrics) error
 
-var config Config
-
 func New(cfg Config) (*Agent, error) {
 	if cfg.Timeout == 0 {
 		return nil, fmt.Errorf("you need to ask TimeoutTimeout")
@@ -63,15 +89,40 @@ func New(cfg Config) (*Agent, error) {
 	if cfg.Address == "" {
 		return nil, fmt.Errorf("you need to ask server address")
 	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = defaultShutdownTimeout
+	}
+
+	logTail := crashreporter.NewLogTail(logTailSize)
 
-	config = cfg
+	logger, err := NewLogger(cfg.LogLevel, cfg.Alias, logTail)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up logger: %w", err)
+	}
 
 	a := &Agent{
-		Metrics: metrics.New(),
-		storage: storage.New(),
-		client:  resty.New(),
+		Metrics:       metrics.New(),
+		cfg:           cfg,
+		storage:       storage.New(),
+		client:        resty.New(),
+		logger:        logger,
+		crashReporter: crashreporter.New(cfg.CrashReportURL, cfg.CrashSpoolDir, logTail, logger),
 	}
 	a.client.SetTimeout(cfg.Timeout)
+	a.crashReporter.RetrySpooled()
+
+	switch cfg.TransportKind {
+	case TransportGRPC:
+		t, err := NewGRPCTransport(cfg.Address)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up gRPC transport: %w", err)
+		}
+		a.transport = t
+	case TransportHTTP, "":
+		a.transport = NewHTTPTransport(a.client, cfg.Address)
+	default:
+		return nil, fmt.Errorf("unknown transport kind %q", cfg.TransportKind)
+	}
 
 	return a, nil
 }
@@ -79,36 +130,72 @@ func New(cfg Config) (*Agent, error) {
 func (a *Agent) Run() {
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
+	defer func() {
+		if err := a.transport.Close(); err != nil {
+			a.logger.Warnf("Failed to close transport: %v", err)
+		}
+	}()
 
-	metricsCh := make(chan metrics.Metrics, config.RateLimit)
-	defer close(metricsCh)
+	metricsCh := make(chan metrics.Metrics, a.cfg.RateLimit)
 
-	go a.RunPool(ctx, metricsCh)
-	go a.GopsutilTicker(ctx, metricsCh)
-	go a.RunReport(ctx, metricsCh)
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() { defer wg.Done(); a.RunPool(ctx, metricsCh) }()
+	go func() { defer wg.Done(); a.GopsutilTicker(ctx, metricsCh) }()
+	go func() { defer wg.Done(); a.RunReport(ctx, metricsCh) }()
 
 	c := make(chan os.Signal, 1)
 	signal.Notify(c, syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT)
 	sig := <-c
-	log.Println("Shutdown signal received:", sig)
-	log.Println("Agent work completed")
+	a.logger.Infof("Shutdown signal received: %v", sig)
+
+	// Stop the producers/reporter before closing metricsCh: closing a
+	// channel while goroutines may still send on it panics. RunReport
+	// doesn't return the instant ctx is cancelled either - it flushes
+	// whatever's already buffered in metricsCh first (flushPending), so
+	// this also waits out that flush.
+	cancel()
+
+	producersDone := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(producersDone)
+	}()
+
+	select {
+	case <-producersDone:
+		// All producers have returned, so nothing can still be sending on
+		// metricsCh: safe to close.
+		close(metricsCh)
+	case <-time.After(a.cfg.ShutdownTimeout):
+		// A producer is still running (or ShutdownTimeout is unset/zero).
+		// Don't close metricsCh here: RunPool/GopsutilTicker/RunReport may
+		// still be mid-send, and closing out from under them would panic.
+		// Leaving it open is harmless - it's simply garbage collected once
+		// the stray goroutine(s) eventually exit.
+		a.logger.Warnf("Shutdown timed out after %v waiting for producers to stop", a.cfg.ShutdownTimeout)
+	}
+
+	a.logger.Infof("Agent work completed")
 }
 
-func (a *Agent) RunPool(ctx context.Context, metricsCh chan<- metrics.Metrics) {
-	ticker := time.NewTicker(config.PollInterval)
+func (a *Agent) RunPool(ctx context.Context, metricsCh chan metrics.Metrics) {
+	defer a.crashReporter.Recover()
+
+	ticker := time.NewTicker(a.cfg.PollInterval)
 	for {
 		select {
 		case <-ticker.C:
-			a.Update(metricsCh)
+			a.Update(ctx, metricsCh)
 		case <-ctx.Done():
-			log.Println("Regular completion of the metrics update")
+			a.logger.Infof("Regular completion of the metrics update")
 			ticker.Stop()
 			return
 		}
 	}
 }
 
-func Retry(sender Sender, retries int, delay time.Duration) Sender {
+func (a *Agent) Retry(sender Sender, retries int, delay time.Duration) Sender {
 	return func(ctx context.Context, metricsCh <-chan metrics.Metrics) error {
 		for r := 0; ; r++ {
 			err := sender(ctx, metricsCh)
@@ -116,10 +203,15 @@ func Retry(sender Sender, retries int, delay time.Duration) Sender {
 				return err
 			}
 
-			log.Printf("Function call failed, retrying in %v", delay)
+			var notRetryable *nonRetryableError
+			if errors.As(err, &notRetryable) {
+				return err
+			}
 
 			delay = delay + time.Second*2
 
+			a.logger.Warnf("Function call failed, retrying in %v (attempt %d/%d)", delay, r+1, retries)
+
 			select {
 			case <-time.After(delay):
 			case <-ctx.Done():
@@ -130,32 +222,62 @@ func Retry(sender Sender, retries int, delay time.Duration) Sender {
 }
 
 func (a *Agent) RunReport(ctx context.Context, metricsCh <-chan metrics.Metrics) {
-	ticker := time.NewTicker(config.ReportInterval)
+	defer a.crashReporter.Recover()
+
+	ticker := time.NewTicker(a.cfg.ReportInterval)
 	for {
 		select {
 		case <-ticker.C:
-			fn := Retry(a.sendReportUpdates, 3, 1*time.Second)
+			fn := a.Retry(a.sendReportUpdates, 3, 1*time.Second)
 			err := fn(ctx, metricsCh)
 			if err != nil {
-				log.Println(err)
+				a.logger.Errorf("%v", err)
 			}
 
 		case <-ctx.Done():
-			log.Println("Regular shutdown of sending metrics")
+			a.logger.Infof("Regular shutdown of sending metrics")
 			ticker.Stop()
+			a.flushPending(metricsCh)
 			return
 		}
 	}
 }
 
-func (a *Agent) GopsutilTicker(ctx context.Context, metricsCh chan<- metrics.Metrics) {
-	ticker := time.NewTicker(config.PollInterval)
+// flushPending ships whatever RunPool/GopsutilTicker already published to
+// metricsCh before ctx was cancelled, instead of discarding it: producers
+// stop enqueueing the moment ctx.Done() fires, but anything already
+// buffered would otherwise be lost the instant RunReport returns and
+// Run() closes the channel. Bounded by a.cfg.ShutdownTimeout so a stuck
+// transport can't hang shutdown indefinitely.
+func (a *Agent) flushPending(metricsCh <-chan metrics.Metrics) {
+	deadline := time.Now().Add(a.cfg.ShutdownTimeout)
+
+	for time.Now().Before(deadline) {
+		var prm metrics.Metrics
+		select {
+		case prm = <-metricsCh:
+		default:
+			return
+		}
+
+		flushCtx, cancel := context.WithTimeout(context.Background(), time.Until(deadline))
+		if err := a.sendMetrics(flushCtx, metricsCh, prm); err != nil {
+			a.logger.Errorf("failed to flush pending metrics during shutdown: %v", err)
+		}
+		cancel()
+	}
+}
+
+func (a *Agent) GopsutilTicker(ctx context.Context, metricsCh chan metrics.Metrics) {
+	defer a.crashReporter.Recover()
+
+	ticker := time.NewTicker(a.cfg.PollInterval)
 	for {
 		select {
 		case <-ticker.C:
-			a.gopsutilUpdate(metricsCh)
+			a.gopsutilUpdate(ctx, metricsCh)
 		case <-ctx.Done():
-			log.Println("Regular completion of the metrics update")
+			a.logger.Infof("Regular completion of the metrics update")
 			ticker.Stop()
 			return
 		}
@@ -163,37 +285,46 @@ func (a *Agent) GopsutilTicker(ctx context.Context, metricsCh chan<- metrics.Met
 }
 
 func (a *Agent) sendReportUpdates(ctx context.Context, metricsCh <-chan metrics.Metrics) error {
+	prm := <-metricsCh
+	return a.sendMetrics(ctx, metricsCh, prm)
+}
+
+// sendMetrics builds and ships a batch from an already-received snapshot,
+// so callers that drain metricsCh themselves (flushPending, during
+// shutdown) can reuse the same build/send/remote_write logic as
+// sendReportUpdates without also consuming another item from the channel.
+func (a *Agent) sendMetrics(ctx context.Context, metricsCh <-chan metrics.Metrics, prm metrics.Metrics) error {
 	hm := make([]Metrics, 0, metrics.GaugeLen+metrics.CounterLen)
 	var hash = ""
 
-	prm := <-metricsCh
-
 	for k, v := range prm.Gauges {
 		value := float64(v)
 
-		if config.Key != "" {
-			hash = metrics.GaugeHash(config.Key, string(k), value)
+		if a.cfg.Key != "" {
+			hash = metrics.GaugeHash(a.cfg.Key, string(k), value, a.cfg.BaseLabels)
 		}
 
 		hm = append(hm, Metrics{
-			ID:    string(k),
-			MType: metrics.TypeGauge,
-			Value: metrics.Gauge(value),
-			Hash:  hash,
+			ID:     string(k),
+			MType:  metrics.TypeGauge,
+			Value:  metrics.Gauge(value),
+			Hash:   hash,
+			Labels: a.cfg.BaseLabels,
 		})
 	}
 
 	for k, v := range prm.Counters {
 		delta := int64(v)
 
-		if config.Key != "" {
-			hash = metrics.CounterHash(config.Key, string(k), delta)
+		if a.cfg.Key != "" {
+			hash = metrics.CounterHash(a.cfg.Key, string(k), delta, a.cfg.BaseLabels)
 		}
 		hm = append(hm, Metrics{
-			ID:    string(k),
-			MType: metrics.TypeCounter,
-			Delta: metrics.Counter(delta),
-			Hash:  hash,
+			ID:     string(k),
+			MType:  metrics.TypeCounter,
+			Delta:  metrics.Counter(delta),
+			Hash:   hash,
+			Labels: a.cfg.BaseLabels,
 		})
 	}
 
@@ -201,36 +332,23 @@ func (a *Agent) sendReportUpdates(ctx context.Context, metricsCh <-chan metrics.
 		return fmt.Errorf("%s", "Empty array of metrics, nothing to send")
 	}
 
-	_, err := a.sendUpdates(ctx, hm)
+	err := a.transport.sendUpdates(ctx, hm)
 	if err != nil {
-		a.handleError(err)
+		a.handleError(err, "endpoint", a.cfg.Address, "batch_size", len(hm))
 		return err
 	}
 
-	log.Println("Report sent")
-	return nil
-}
-
-func (a *Agent) sendUpdates(ctx context.Context, hm []Metrics) (*resty.Response, error) {
-	var endpoint = fmt.Sprintf("http://%s/updates/", config.Address)
-
-	resp, err := a.client.R().
-		SetHeader("Accept", "application/json").
-		SetHeader("Accept-Encoding", "gzip").
-		SetHeader("Content-Type", "application/json").
-		SetContext(ctx).
-		SetBody(hm).
-		Post(endpoint)
-
-	if err != nil {
-		return nil, err
-	}
-
-	if resp.StatusCode() != http.StatusOK {
-		return resp, fmt.Errorf("invalid status code %v", resp.StatusCode())
+	if a.cfg.RemoteWriteURL != "" {
+		rw := a.Retry(func(ctx context.Context, _ <-chan metrics.Metrics) error {
+			return a.sendRemoteWrite(ctx, hm)
+		}, 3, 1*time.Second)
+		if err := rw(ctx, metricsCh); err != nil {
+			a.handleError(fmt.Errorf("remote_write: %w", err))
+		}
 	}
 
-	return resp, nil
+	a.logger.Infof("Report sent")
+	return nil
 }
 
 func (a *Agent) sendReport() {
@@ -241,7 +359,7 @@ func (a *Agent) sendReport() {
 		a.sendRequest(key, val)
 	}
 
-	log.Println("Report sent")
+	a.logger.Infof("Report sent")
 }
 
 func Compress(data []byte) ([]byte, error) {
@@ -262,7 +380,7 @@ func Compress(data []byte) ([]byte, error) {
 }
 
 func (a *Agent) sendRequest(key metrics.Name, value any) int {
-	var endpoint = fmt.Sprintf("http://%s/update/", config.Address)
+	var endpoint = fmt.Sprintf("http://%s/update/", a.cfg.Address)
 	var met Metrics
 
 	switch v := value.(type) {
@@ -302,11 +420,18 @@ func (a *Agent) sendRequest(key metrics.Name, value any) int {
 	return response.StatusCode()
 }
 
-func (a *Agent) handleError(err error) {
-	log.Println("Error -", err)
+// handleError logs err with whatever contextual fields the caller can
+// supply (endpoint, batch size, retry attempt, ...) instead of a flat
+// "Error - %v" line.
+func (a *Agent) handleError(err error, fields ...interface{}) {
+	if len(fields) == 0 {
+		a.logger.Errorf("Error - %v", err)
+		return
+	}
+	a.logger.With(fields...).Errorf("Error - %v", err)
 }
 
-func (a *Agent) Update(metricsCh chan<- metrics.Metrics) {
+func (a *Agent) Update(ctx context.Context, metricsCh chan metrics.Metrics) {
 	var mu sync.RWMutex
 	ms := &runtime.MemStats{}
 
@@ -348,12 +473,12 @@ func (a *Agent) Update(metricsCh chan<- metrics.Metrics) {
 	prm.Counters[metrics.PollCount] = 1
 	mu.Unlock()
 
-	metricsCh <- *prm
+	publish(ctx, metricsCh, *prm)
 
-	log.Println("Metrics updated")
+	a.logger.Infof("Metrics updated")
 }
 
-func (a *Agent) gopsutilUpdate(metricsCh chan<- metrics.Metrics) {
+func (a *Agent) gopsutilUpdate(ctx context.Context, metricsCh chan metrics.Metrics) {
 	var mu sync.RWMutex
 	prm := metrics.New()
 
@@ -383,7 +508,7 @@ func (a *Agent) gopsutilUpdate(metricsCh chan<- metrics.Metrics) {
 	prm.Gauges = gauges
 	mu.Unlock()
 
-	metricsCh <- *prm
+	publish(ctx, metricsCh, *prm)
 
-	log.Println("Updated metrics via `gopsutil` package")
+	a.logger.Infof("Updated metrics via `gopsutil` package")
 }
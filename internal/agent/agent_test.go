@@ -0,0 +1,105 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Warnf(string, ...interface{})  {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+func (l nopLogger) With(...interface{}) Logger  { return l }
+
+func TestRetryStopsImmediatelyOnNonRetryableError(t *testing.T) {
+	a := &Agent{logger: nopLogger{}}
+
+	calls := 0
+	sender := func(context.Context, <-chan metrics.Metrics) error {
+		calls++
+		return &nonRetryableError{err: errors.New("rejected")}
+	}
+
+	fn := a.Retry(sender, 3, 0)
+	if err := fn(context.Background(), nil); err == nil {
+		t.Error("Retry() error = nil, want the non-retryable error")
+	}
+
+	if calls != 1 {
+		t.Errorf("sender called %d times, want exactly 1 (no retries)", calls)
+	}
+}
+
+type recordingTransport struct {
+	mu  sync.Mutex
+	hms [][]Metrics
+}
+
+func (t *recordingTransport) sendUpdates(_ context.Context, hm []Metrics) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.hms = append(t.hms, hm)
+	return nil
+}
+
+func (t *recordingTransport) Close() error { return nil }
+
+func TestFlushPendingSendsWhatsAlreadyBuffered(t *testing.T) {
+	transport := &recordingTransport{}
+	a := &Agent{logger: nopLogger{}, transport: transport, cfg: Config{ShutdownTimeout: time.Second}}
+
+	metricsCh := make(chan metrics.Metrics, 2)
+	metricsCh <- metrics.Metrics{Counters: map[metrics.Name]metrics.Counter{"PollCount": 1}}
+	metricsCh <- metrics.Metrics{Gauges: map[metrics.Name]metrics.Gauge{"Alloc": 42}}
+
+	a.flushPending(metricsCh)
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	if len(transport.hms) != 2 {
+		t.Fatalf("transport received %d batches, want 2 (both buffered snapshots flushed)", len(transport.hms))
+	}
+}
+
+func TestFlushPendingReturnsImmediatelyWhenEmpty(t *testing.T) {
+	a := &Agent{logger: nopLogger{}, transport: &recordingTransport{}, cfg: Config{ShutdownTimeout: time.Minute}}
+	metricsCh := make(chan metrics.Metrics)
+
+	done := make(chan struct{})
+	go func() {
+		a.flushPending(metricsCh)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("flushPending blocked instead of returning immediately on an empty channel")
+	}
+}
+
+func TestRetryRetriesOrdinaryErrors(t *testing.T) {
+	a := &Agent{logger: nopLogger{}}
+
+	calls := 0
+	sender := func(context.Context, <-chan metrics.Metrics) error {
+		calls++
+		return errors.New("transient failure")
+	}
+
+	fn := a.Retry(sender, 2, 0)
+	if err := fn(context.Background(), nil); err == nil {
+		t.Error("Retry() error = nil, want the last failure")
+	}
+
+	if calls != 3 {
+		t.Errorf("sender called %d times, want 3 (1 initial + 2 retries)", calls)
+	}
+}
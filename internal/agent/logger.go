@@ -0,0 +1,59 @@
+package agent
+
+import (
+	"io"
+	"os"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Logger is the structured logging surface used throughout the agent.
+// With returns a child Logger that prefixes every subsequent line with the
+// given key/value fields, mirroring how input-plugin frameworks let each
+// instance name itself (e.g. Config.Alias on a multi-agent supervisor).
+type Logger interface {
+	Debugf(template string, args ...interface{})
+	Infof(template string, args ...interface{})
+	Warnf(template string, args ...interface{})
+	Errorf(template string, args ...interface{})
+	With(fields ...interface{}) Logger
+}
+
+type zapLogger struct {
+	sugar *zap.SugaredLogger
+}
+
+// NewLogger builds the default Logger, leveled by Config.LogLevel
+// ("debug"/"info"/"warn"/"error", defaulting to "info") and tagged with
+// Config.Alias when set, so multiple agents in one process can be told
+// apart in the log stream. Every line is also written to tail (if non-nil),
+// e.g. so a crash report can carry the log context leading up to a panic.
+func NewLogger(level, alias string, tail io.Writer) (Logger, error) {
+	zapLevel, err := zap.ParseAtomicLevel(level)
+	if err != nil {
+		zapLevel = zap.NewAtomicLevelAt(zap.InfoLevel)
+	}
+
+	encoder := zapcore.NewJSONEncoder(zap.NewProductionEncoderConfig())
+	core := zapcore.NewCore(encoder, zapcore.Lock(os.Stdout), zapLevel)
+	if tail != nil {
+		core = zapcore.NewTee(core, zapcore.NewCore(encoder, zapcore.AddSync(tail), zapLevel))
+	}
+
+	sugar := zap.New(core).Sugar()
+	if alias != "" {
+		sugar = sugar.With("alias", alias)
+	}
+
+	return &zapLogger{sugar: sugar}, nil
+}
+
+func (l *zapLogger) Debugf(template string, args ...interface{}) { l.sugar.Debugf(template, args...) }
+func (l *zapLogger) Infof(template string, args ...interface{})  { l.sugar.Infof(template, args...) }
+func (l *zapLogger) Warnf(template string, args ...interface{})  { l.sugar.Warnf(template, args...) }
+func (l *zapLogger) Errorf(template string, args ...interface{}) { l.sugar.Errorf(template, args...) }
+
+func (l *zapLogger) With(fields ...interface{}) Logger {
+	return &zapLogger{sugar: l.sugar.With(fields...)}
+}
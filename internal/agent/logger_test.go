@@ -0,0 +1,57 @@
+package agent
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestNewLoggerFallsBackToInfoOnBogusLevel(t *testing.T) {
+	var tail bytes.Buffer
+
+	logger, err := NewLogger("not-a-real-level", "", &tail)
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v, want a bogus level to fall back to info instead of failing", err)
+	}
+
+	logger.Debugf("should be suppressed")
+	logger.Infof("should appear")
+
+	out := tail.String()
+	if strings.Contains(out, "should be suppressed") {
+		t.Errorf("expected debug line to be suppressed at the info fallback level, got %q", out)
+	}
+	if !strings.Contains(out, "should appear") {
+		t.Errorf("expected info line to appear, got %q", out)
+	}
+}
+
+func TestNewLoggerTagsLinesWithAlias(t *testing.T) {
+	var tail bytes.Buffer
+
+	logger, err := NewLogger("info", "agent-1", &tail)
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	logger.Infof("hello")
+
+	if !strings.Contains(tail.String(), `"alias":"agent-1"`) {
+		t.Errorf("expected log line to carry the alias field, got %q", tail.String())
+	}
+}
+
+func TestLoggerWithAddsFieldsToSubsequentLines(t *testing.T) {
+	var tail bytes.Buffer
+
+	logger, err := NewLogger("info", "", &tail)
+	if err != nil {
+		t.Fatalf("NewLogger() error = %v", err)
+	}
+
+	logger.With("endpoint", "localhost:8080").Errorf("boom")
+
+	if !strings.Contains(tail.String(), `"endpoint":"localhost:8080"`) {
+		t.Errorf("expected With()'s fields to appear on the logged line, got %q", tail.String())
+	}
+}
@@ -0,0 +1,49 @@
+package proto
+
+import (
+	"fmt"
+
+	"google.golang.org/grpc/encoding"
+)
+
+// CodecName is negotiated per-RPC via grpc.CallContentSubtype on the
+// client (see transport.go's NewGRPCTransport); grpc-go derives the codec
+// to use on the server side from the request's grpc-content-type header,
+// so MetricsServer picks this codec up automatically without needing a
+// ForceServerCodec ServerOption wherever grpc.NewServer is constructed.
+const CodecName = "metricswire"
+
+type wireMarshaler interface {
+	Marshal() ([]byte, error)
+}
+
+type wireUnmarshaler interface {
+	Unmarshal([]byte) error
+}
+
+// wireCodec adapts Metric/UpdateBatchResponse's hand-written Marshal and
+// Unmarshal methods to grpc's encoding.Codec, since neither type
+// implements proto.Message (see metrics.pb.go).
+type wireCodec struct{}
+
+func (wireCodec) Name() string { return CodecName }
+
+func (wireCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(wireMarshaler)
+	if !ok {
+		return nil, fmt.Errorf("proto: %T does not implement Marshal() ([]byte, error)", v)
+	}
+	return m.Marshal()
+}
+
+func (wireCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(wireUnmarshaler)
+	if !ok {
+		return fmt.Errorf("proto: %T does not implement Unmarshal([]byte) error", v)
+	}
+	return m.Unmarshal(data)
+}
+
+func init() {
+	encoding.RegisterCodec(wireCodec{})
+}
@@ -0,0 +1,195 @@
+// Hand-maintained counterpart of proto/metrics/metrics.proto: this tree's
+// build doesn't have protoc-gen-go available, so Metric and
+// UpdateBatchResponse carry their own Marshal/Unmarshal methods (see
+// wire.go) instead of the ProtoReflect() surface protoc-gen-go would emit.
+// They're shipped over gRPC via codec.go's wireCodec, not the default
+// "proto" codec. Keep this file's fields in sync with the .proto by hand.
+
+package proto
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"sort"
+)
+
+var errTruncatedFixed64 = errors.New("proto: truncated fixed64 field")
+
+type Metric struct {
+	Id     string            `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Type   string            `protobuf:"bytes,2,opt,name=type,proto3" json:"type,omitempty"`
+	Delta  int64             `protobuf:"varint,3,opt,name=delta,proto3" json:"delta,omitempty"`
+	Value  float64           `protobuf:"fixed64,4,opt,name=value,proto3" json:"value,omitempty"`
+	Hash   string            `protobuf:"bytes,5,opt,name=hash,proto3" json:"hash,omitempty"`
+	Labels map[string]string `protobuf:"bytes,6,rep,name=labels,proto3" json:"labels,omitempty"`
+}
+
+// Marshal encodes m using the wire format described by metrics.proto: each
+// map entry is its own length-delimited LabelsEntry{key=1,value=2}
+// submessage under field 6, same as protoc would generate for a proto3 map
+// field. Keys are sorted first only for deterministic output; the wire
+// format doesn't require it.
+func (m *Metric) Marshal() ([]byte, error) {
+	var buf []byte
+	buf = appendStringField(buf, 1, m.Id)
+	buf = appendStringField(buf, 2, m.Type)
+	buf = appendVarintField(buf, 3, uint64(m.Delta))
+	buf = appendFixed64Field(buf, 4, math.Float64bits(m.Value))
+	buf = appendStringField(buf, 5, m.Hash)
+
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		var entry []byte
+		entry = appendStringField(entry, 1, k)
+		entry = appendStringField(entry, 2, m.Labels[k])
+		buf = appendTag(buf, 6, 2)
+		buf = appendVarint(buf, uint64(len(entry)))
+		buf = append(buf, entry...)
+	}
+
+	return buf, nil
+}
+
+func (m *Metric) Unmarshal(data []byte) error {
+	*m = Metric{}
+
+	for len(data) > 0 {
+		num, wireType, n, err := consumeTag(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+
+		switch wireType {
+		case 0:
+			v, n, err := consumeVarint(data)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+			if num == 3 {
+				m.Delta = int64(v)
+			}
+		case 1:
+			if len(data) < 8 {
+				return errTruncatedFixed64
+			}
+			bits := binary.LittleEndian.Uint64(data[:8])
+			data = data[8:]
+			if num == 4 {
+				m.Value = math.Float64frombits(bits)
+			}
+		case 2:
+			b, n, err := consumeBytes(data)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+			switch num {
+			case 1:
+				m.Id = string(b)
+			case 2:
+				m.Type = string(b)
+			case 5:
+				m.Hash = string(b)
+			case 6:
+				key, value, err := unmarshalLabelsEntry(b)
+				if err != nil {
+					return err
+				}
+				if m.Labels == nil {
+					m.Labels = make(map[string]string)
+				}
+				m.Labels[key] = value
+			}
+		default:
+			n, err := skipField(data, wireType)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+	}
+
+	return nil
+}
+
+func unmarshalLabelsEntry(data []byte) (key, value string, err error) {
+	for len(data) > 0 {
+		num, wireType, n, err := consumeTag(data)
+		if err != nil {
+			return "", "", err
+		}
+		data = data[n:]
+
+		switch wireType {
+		case 2:
+			b, n, err := consumeBytes(data)
+			if err != nil {
+				return "", "", err
+			}
+			data = data[n:]
+			switch num {
+			case 1:
+				key = string(b)
+			case 2:
+				value = string(b)
+			}
+		default:
+			n, err := skipField(data, wireType)
+			if err != nil {
+				return "", "", err
+			}
+			data = data[n:]
+		}
+	}
+	return key, value, nil
+}
+
+type UpdateBatchResponse struct {
+	Accepted int32 `protobuf:"varint,1,opt,name=accepted,proto3" json:"accepted,omitempty"`
+}
+
+func (r *UpdateBatchResponse) Marshal() ([]byte, error) {
+	var buf []byte
+	buf = appendVarintField(buf, 1, uint64(uint32(r.Accepted)))
+	return buf, nil
+}
+
+func (r *UpdateBatchResponse) Unmarshal(data []byte) error {
+	*r = UpdateBatchResponse{}
+
+	for len(data) > 0 {
+		num, wireType, n, err := consumeTag(data)
+		if err != nil {
+			return err
+		}
+		data = data[n:]
+
+		switch wireType {
+		case 0:
+			v, n, err := consumeVarint(data)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+			if num == 1 {
+				r.Accepted = int32(v)
+			}
+		default:
+			n, err := skipField(data, wireType)
+			if err != nil {
+				return err
+			}
+			data = data[n:]
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package proto
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+)
+
+func TestMetricRoundTripsThroughRegisteredCodec(t *testing.T) {
+	codec := encoding.GetCodec(CodecName)
+	if codec == nil {
+		t.Fatalf("codec %q is not registered", CodecName)
+	}
+
+	in := &Metric{
+		Id:     "PollCount",
+		Type:   "counter",
+		Delta:  42,
+		Hash:   "deadbeef",
+		Labels: map[string]string{"host": "agent-1", "env": "prod"},
+	}
+
+	data, err := codec.Marshal(in)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	out := new(Metric)
+	if err := codec.Unmarshal(data, out); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if !reflect.DeepEqual(in, out) {
+		t.Errorf("round trip mismatch: got %+v, want %+v", out, in)
+	}
+}
+
+func TestMetricRoundTripGaugeValue(t *testing.T) {
+	in := &Metric{Id: "Alloc", Type: "gauge", Value: 3.14159}
+
+	data, err := in.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	out := new(Metric)
+	if err := out.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if out.Value != in.Value {
+		t.Errorf("Value = %v, want %v", out.Value, in.Value)
+	}
+}
+
+func TestUpdateBatchResponseRoundTrip(t *testing.T) {
+	in := &UpdateBatchResponse{Accepted: 7}
+
+	data, err := in.Marshal()
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	out := new(UpdateBatchResponse)
+	if err := out.Unmarshal(data); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if out.Accepted != in.Accepted {
+		t.Errorf("Accepted = %d, want %d", out.Accepted, in.Accepted)
+	}
+}
@@ -0,0 +1,112 @@
+package proto
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+// This file implements just enough of the protobuf wire format (varint,
+// length-delimited and fixed64 fields) for Metric and UpdateBatchResponse
+// to marshal and unmarshal themselves by hand. metrics.pb.go isn't real
+// protoc-gen-go output, so these types have no proto.Message/protoreflect
+// surface; MetricsService ships them over the wire through codec.go's
+// wireCodec instead of grpc-go's default reflection-based "proto" codec.
+
+func appendVarint(buf []byte, v uint64) []byte {
+	for v >= 0x80 {
+		buf = append(buf, byte(v)|0x80)
+		v >>= 7
+	}
+	return append(buf, byte(v))
+}
+
+func appendTag(buf []byte, fieldNum int, wireType byte) []byte {
+	return appendVarint(buf, uint64(fieldNum)<<3|uint64(wireType))
+}
+
+func appendStringField(buf []byte, fieldNum int, s string) []byte {
+	if s == "" {
+		return buf
+	}
+	buf = appendTag(buf, fieldNum, 2)
+	buf = appendVarint(buf, uint64(len(s)))
+	return append(buf, s...)
+}
+
+func appendVarintField(buf []byte, fieldNum int, v uint64) []byte {
+	if v == 0 {
+		return buf
+	}
+	buf = appendTag(buf, fieldNum, 0)
+	return appendVarint(buf, v)
+}
+
+func appendFixed64Field(buf []byte, fieldNum int, bits uint64) []byte {
+	if bits == 0 {
+		return buf
+	}
+	buf = appendTag(buf, fieldNum, 1)
+	var tmp [8]byte
+	binary.LittleEndian.PutUint64(tmp[:], bits)
+	return append(buf, tmp[:]...)
+}
+
+func consumeVarint(data []byte) (v uint64, n int, err error) {
+	for shift := uint(0); n < len(data); shift += 7 {
+		if shift >= 64 {
+			return 0, 0, fmt.Errorf("proto: varint overflow")
+		}
+		b := data[n]
+		v |= uint64(b&0x7f) << shift
+		n++
+		if b < 0x80 {
+			return v, n, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("proto: truncated varint")
+}
+
+func consumeTag(data []byte) (fieldNum int, wireType byte, n int, err error) {
+	v, n, err := consumeVarint(data)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return int(v >> 3), byte(v & 0x7), n, nil
+}
+
+func consumeBytes(data []byte) (b []byte, n int, err error) {
+	l, ln, err := consumeVarint(data)
+	if err != nil {
+		return nil, 0, err
+	}
+	end := ln + int(l)
+	if l > uint64(len(data)) || end < ln || end > len(data) {
+		return nil, 0, fmt.Errorf("proto: truncated length-delimited field")
+	}
+	return data[ln:end], end, nil
+}
+
+// skipField advances past a field of the given wire type whose tag has
+// already been consumed, returning the number of bytes it occupied.
+func skipField(data []byte, wireType byte) (n int, err error) {
+	switch wireType {
+	case 0:
+		_, n, err = consumeVarint(data)
+		return n, err
+	case 1:
+		if len(data) < 8 {
+			return 0, fmt.Errorf("proto: truncated fixed64 field")
+		}
+		return 8, nil
+	case 2:
+		_, n, err := consumeBytes(data)
+		return n, err
+	case 5:
+		if len(data) < 4 {
+			return 0, fmt.Errorf("proto: truncated fixed32 field")
+		}
+		return 4, nil
+	default:
+		return 0, fmt.Errorf("proto: unsupported wire type %d", wireType)
+	}
+}
@@ -0,0 +1,63 @@
+package agent
+
+import (
+	"context"
+	"time"
+
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	metricsPublishedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "metrics_published_total",
+		Help: "Total number of metric batches successfully queued for reporting.",
+	})
+	metricsDroppedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "metrics_dropped_total",
+		Help: "Total number of metric batches dropped because the publish queue was full.",
+	})
+	publishLatencySeconds = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name: "publish_latency_seconds",
+		Help: "Time spent publishing a metric batch onto the channel consumed by RunReport.",
+	})
+	publishWaitSeconds = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "publish_wait_seconds",
+		Help: "Time the most recent publish call spent waiting on a full channel.",
+	})
+)
+
+// publish sends m to metricsCh without letting a slow or stalled receiver
+// block the caller: if the channel is already full, the oldest pending
+// batch is dropped to make room for m rather than stalling Update or
+// gopsutilUpdate indefinitely.
+func publish(ctx context.Context, metricsCh chan metrics.Metrics, m metrics.Metrics) {
+	start := time.Now()
+	defer func() {
+		wait := time.Since(start)
+		publishLatencySeconds.Observe(wait.Seconds())
+		publishWaitSeconds.Set(wait.Seconds())
+	}()
+
+	select {
+	case metricsCh <- m:
+		metricsPublishedTotal.Inc()
+		return
+	case <-ctx.Done():
+		return
+	default:
+	}
+
+	select {
+	case <-metricsCh:
+		metricsDroppedTotal.Inc()
+	default:
+	}
+
+	select {
+	case metricsCh <- m:
+		metricsPublishedTotal.Inc()
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,40 @@
+package agent
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+)
+
+func TestPublishDropsOldestWhenFull(t *testing.T) {
+	ch := make(chan metrics.Metrics, 1)
+	ctx := context.Background()
+
+	first := metrics.Metrics{Counters: map[metrics.Name]metrics.Counter{"first": 1}}
+	second := metrics.Metrics{Counters: map[metrics.Name]metrics.Counter{"second": 1}}
+
+	publish(ctx, ch, first)
+	publish(ctx, ch, second)
+
+	got := <-ch
+	if _, ok := got.Counters["second"]; !ok {
+		t.Fatalf("expected the oldest batch to be dropped in favor of the newest, got %+v", got)
+	}
+}
+
+func TestPublishDoesNotBlockOnCanceledContext(t *testing.T) {
+	ch := make(chan metrics.Metrics, 1)
+	ch <- metrics.Metrics{}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan struct{})
+	go func() {
+		publish(ctx, ch, metrics.Metrics{})
+		close(done)
+	}()
+
+	<-done
+}
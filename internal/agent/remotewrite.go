@@ -0,0 +1,154 @@
+package agent
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+	"github.com/gogo/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// remote_write expects counters to be cumulative, monotonically
+// increasing samples (the same convention Prometheus's own client
+// libraries use), but sendReportUpdates only ever hands buildWriteRequest
+// the delta collected since the last report. Agent.cumulativeCounters
+// tracks the running total per metric ID across reports, mirroring the
+// `+=` storage.MemStorage.UpdateCounter does for the primary transport.
+//
+// This lives on Agent rather than as a package global: a package-level
+// map keyed by bare ID would sum the totals of every Agent sharing the
+// process (chunk0-4's Alias/multi-agent support means there can be more
+// than one), and would leak state between independent agents/tests.
+func (a *Agent) accumulateCounter(id string, delta int64) int64 {
+	a.cumulativeCountersMu.Lock()
+	defer a.cumulativeCountersMu.Unlock()
+	if a.cumulativeCounters == nil {
+		a.cumulativeCounters = map[string]int64{}
+	}
+	a.cumulativeCounters[id] += delta
+	return a.cumulativeCounters[id]
+}
+
+// nonRetryableError marks a failure that retrying won't fix — a rejected
+// batch is still rejected on resend — so Agent.Retry can stop immediately
+// instead of burning its retry budget on a guaranteed repeat failure.
+type nonRetryableError struct {
+	err error
+}
+
+func (e *nonRetryableError) Error() string { return e.err.Error() }
+func (e *nonRetryableError) Unwrap() error { return e.err }
+
+// invalidMetricNameChars matches anything Prometheus doesn't allow in a
+// metric name, so we can fold the agent's metric IDs into __name__.
+var invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+func sanitizeMetricName(id string) string {
+	return invalidMetricNameChars.ReplaceAllString(id, "_")
+}
+
+// buildWriteRequest turns the batch built by sendReportUpdates into a
+// Prometheus remote_write WriteRequest, one TimeSeries per metric, with
+// counters mapped to monotonic samples and gauges passed through directly.
+func (a *Agent) buildWriteRequest(hm []Metrics) *prompb.WriteRequest {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	now := time.Now().UnixMilli()
+
+	baseLabels := map[string]string{
+		"instance": hostname,
+		"job":      a.cfg.Job,
+	}
+	for k, v := range a.cfg.BaseLabels {
+		baseLabels[k] = v
+	}
+	for k, v := range a.cfg.ExternalLabels {
+		baseLabels[k] = v
+	}
+
+	wr := &prompb.WriteRequest{
+		Timeseries: make([]prompb.TimeSeries, 0, len(hm)),
+	}
+
+	for _, m := range hm {
+		var value float64
+		switch m.MType {
+		case metrics.TypeCounter:
+			value = float64(a.accumulateCounter(m.ID, int64(m.Delta)))
+		case metrics.TypeGauge:
+			value = float64(m.Value)
+		default:
+			continue
+		}
+
+		labels := make(map[string]string, len(baseLabels)+1)
+		for k, v := range baseLabels {
+			labels[k] = v
+		}
+		labels["__name__"] = sanitizeMetricName(m.ID)
+
+		names := make([]string, 0, len(labels))
+		for k := range labels {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+
+		pbLabels := make([]prompb.Label, 0, len(names))
+		for _, name := range names {
+			pbLabels = append(pbLabels, prompb.Label{Name: name, Value: labels[name]})
+		}
+
+		wr.Timeseries = append(wr.Timeseries, prompb.TimeSeries{
+			Labels:  pbLabels,
+			Samples: []prompb.Sample{{Value: value, Timestamp: now}},
+		})
+	}
+
+	return wr
+}
+
+// sendRemoteWrite ships the same batch sendReportUpdates built for the
+// primary transport to a Prometheus-compatible remote_write endpoint
+// (Mimir, VictoriaMetrics, Thanos Receive, ...), so runtime/gopsutil
+// metrics land in a TSDB without a separate exporter.
+func (a *Agent) sendRemoteWrite(ctx context.Context, hm []Metrics) error {
+	data, err := proto.Marshal(a.buildWriteRequest(hm))
+	if err != nil {
+		return fmt.Errorf("failed to marshal remote_write request: %w", err)
+	}
+
+	resp, err := a.client.R().
+		SetHeader("Content-Encoding", "snappy").
+		SetHeader("Content-Type", "application/x-protobuf").
+		SetHeader("X-Prometheus-Remote-Write-Version", "0.1.0").
+		SetContext(ctx).
+		SetBody(snappy.Encode(nil, data)).
+		Post(a.cfg.RemoteWriteURL)
+
+	if err != nil {
+		return err
+	}
+
+	if resp.StatusCode() == http.StatusOK || resp.StatusCode() == http.StatusNoContent {
+		return nil
+	}
+
+	rejectErr := fmt.Errorf("remote_write rejected batch with status %v", resp.StatusCode())
+	if resp.StatusCode() >= 500 {
+		return rejectErr
+	}
+
+	// A 4xx means the endpoint rejected this exact batch (bad auth, bad
+	// payload, ...); resending it unchanged would only fail again.
+	return &nonRetryableError{err: rejectErr}
+}
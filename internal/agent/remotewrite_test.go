@@ -0,0 +1,152 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+func TestSendRemoteWriteRejects4xxAsNonRetryable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	a := &Agent{client: resty.New(), cfg: Config{RemoteWriteURL: srv.URL}}
+	err := a.sendRemoteWrite(context.Background(), []Metrics{{ID: "PollCount", MType: "counter", Delta: 1}})
+
+	var notRetryable *nonRetryableError
+	if !errors.As(err, &notRetryable) {
+		t.Fatalf("sendRemoteWrite() error = %v, want a *nonRetryableError", err)
+	}
+}
+
+func TestSendRemoteWriteKeeps5xxRetryable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	a := &Agent{client: resty.New(), cfg: Config{RemoteWriteURL: srv.URL}}
+	err := a.sendRemoteWrite(context.Background(), []Metrics{{ID: "PollCount", MType: "counter", Delta: 1}})
+
+	var notRetryable *nonRetryableError
+	if errors.As(err, &notRetryable) {
+		t.Fatalf("sendRemoteWrite() error = %v, want a retryable error, not *nonRetryableError", err)
+	}
+	if err == nil {
+		t.Fatal("sendRemoteWrite() error = nil, want an error for a 500 response")
+	}
+}
+
+func TestSanitizeMetricName(t *testing.T) {
+	cases := map[string]string{
+		"PollCount":        "PollCount",
+		"CPUutilization0":  "CPUutilization0",
+		"request.duration": "request_duration",
+		"go-routines":      "go_routines",
+	}
+
+	for in, want := range cases {
+		if got := sanitizeMetricName(in); got != want {
+			t.Errorf("sanitizeMetricName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestBuildWriteRequestAppliesBaseAndExternalLabels(t *testing.T) {
+	a := &Agent{cfg: Config{
+		Job:            "agent",
+		BaseLabels:     map[string]string{"host": "agent-1"},
+		ExternalLabels: map[string]string{"env": "prod"},
+	}}
+
+	hm := []Metrics{{ID: "PollCount", MType: "counter", Delta: 3}}
+	wr := a.buildWriteRequest(hm)
+
+	if len(wr.Timeseries) != 1 {
+		t.Fatalf("expected 1 series, got %d", len(wr.Timeseries))
+	}
+
+	labels := map[string]string{}
+	for _, l := range wr.Timeseries[0].Labels {
+		labels[l.Name] = l.Value
+	}
+
+	if labels["__name__"] != "PollCount" {
+		t.Errorf("__name__ = %q, want PollCount", labels["__name__"])
+	}
+	if labels["host"] != "agent-1" {
+		t.Errorf("host label = %q, want agent-1", labels["host"])
+	}
+	if labels["env"] != "prod" {
+		t.Errorf("env label = %q, want prod", labels["env"])
+	}
+
+	if len(wr.Timeseries[0].Samples) != 1 || wr.Timeseries[0].Samples[0].Value != 3 {
+		t.Errorf("unexpected samples: %+v", wr.Timeseries[0].Samples)
+	}
+}
+
+func TestBuildWriteRequestAccumulatesCounterAcrossReports(t *testing.T) {
+	id := "RemoteWriteCumulativeCounter"
+	a := &Agent{cfg: Config{Job: "agent"}}
+
+	wr := a.buildWriteRequest([]Metrics{{ID: id, MType: "counter", Delta: 2}})
+	if got := wr.Timeseries[0].Samples[0].Value; got != 2 {
+		t.Fatalf("first report: value = %v, want 2", got)
+	}
+
+	wr = a.buildWriteRequest([]Metrics{{ID: id, MType: "counter", Delta: 5}})
+	if got := wr.Timeseries[0].Samples[0].Value; got != 7 {
+		t.Errorf("second report: value = %v, want 7 (cumulative)", got)
+	}
+}
+
+func TestBuildWriteRequestCountersAreIsolatedPerAgent(t *testing.T) {
+	id := "PollCount"
+	first := &Agent{cfg: Config{Job: "agent"}}
+	second := &Agent{cfg: Config{Job: "agent"}}
+
+	first.buildWriteRequest([]Metrics{{ID: id, MType: "counter", Delta: 1}})
+
+	wr := second.buildWriteRequest([]Metrics{{ID: id, MType: "counter", Delta: 1}})
+	if got := wr.Timeseries[0].Samples[0].Value; got != 1 {
+		t.Errorf("second agent's running total = %v, want 1 (independent of first agent)", got)
+	}
+}
+
+// TestTwoAgentsKeepIndependentConfig guards the bug fixed alongside this
+// test: Config used to live in a package-level var that New() overwrote
+// on every call, so a second agent.New(cfg2) silently clobbered the
+// first agent's Address/Key/labels out from under its already-running
+// goroutines. Each Agent now carries its own cfg.
+func TestTwoAgentsKeepIndependentConfig(t *testing.T) {
+	base := Config{Timeout: time.Second, PollInterval: time.Second, ReportInterval: time.Second}
+
+	firstCfg := base
+	firstCfg.Address, firstCfg.Key = "first:8080", "first-key"
+	first, err := New(firstCfg)
+	if err != nil {
+		t.Fatalf("New(first) error = %v", err)
+	}
+
+	secondCfg := base
+	secondCfg.Address, secondCfg.Key = "second:8080", "second-key"
+	second, err := New(secondCfg)
+	if err != nil {
+		t.Fatalf("New(second) error = %v", err)
+	}
+
+	if first.cfg.Address == second.cfg.Address || first.cfg.Key == second.cfg.Key {
+		t.Fatalf("expected independent configs, got first=%+v second=%+v", first.cfg, second.cfg)
+	}
+	if first.cfg.Address != "first:8080" || first.cfg.Key != "first-key" {
+		t.Errorf("first agent's config was overwritten: %+v", first.cfg)
+	}
+}
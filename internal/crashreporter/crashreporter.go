@@ -0,0 +1,271 @@
+// Package crashreporter recovers panics in the agent's long-running
+// goroutines and ships a report (stack trace, build info, recent log
+// lines) to a configurable endpoint, so a crash in the field doesn't just
+// vanish into a restarted process.
+package crashreporter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-resty/resty/v2"
+)
+
+// rateLimitWindow bounds how often the same panic fingerprint is reported,
+// so an agent stuck in a crash loop doesn't DoS the receiver.
+const rateLimitWindow = time.Hour
+
+// topFrames is how many lines of the stack trace are hashed into the
+// fingerprint (two lines per frame: function, then file:line); deep frames
+// tend to differ between otherwise-identical panics.
+const topFrames = 8
+
+type Report struct {
+	Fingerprint string    `json:"fingerprint"`
+	Panic       string    `json:"panic"`
+	Stack       string    `json:"stack"`
+	BuildInfo   string    `json:"build_info"`
+	LogTail     []string  `json:"log_tail,omitempty"`
+	OccurredAt  time.Time `json:"occurred_at"`
+}
+
+// Logger is the minimal logging surface Reporter needs. agent.Logger
+// satisfies it; it's declared locally so this package doesn't import
+// agent (which already imports crashreporter).
+type Logger interface {
+	Errorf(template string, args ...interface{})
+}
+
+// Reporter recovers panics and turns them into Reports. The zero value is
+// not usable; construct with New.
+type Reporter struct {
+	url      string
+	spoolDir string
+	client   *resty.Client
+	logTail  *LogTail
+	logger   Logger
+
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func New(url, spoolDir string, logTail *LogTail, logger Logger) *Reporter {
+	return &Reporter{
+		url:      url,
+		spoolDir: spoolDir,
+		client:   resty.New(),
+		logTail:  logTail,
+		logger:   logger,
+		seen:     make(map[string]time.Time),
+	}
+}
+
+// Recover is meant to be deferred at the top of a goroutine:
+//
+//	func (a *Agent) RunPool(ctx context.Context, metricsCh chan metrics.Metrics) {
+//	    defer a.crashReporter.Recover()
+//	    ...
+//	}
+//
+// It swallows the panic after reporting it, so the caller's process stays
+// up; the supervisor that started the goroutine is responsible for
+// restarting it if that's desired.
+func (r *Reporter) Recover() {
+	rec := recover()
+	if rec == nil {
+		return
+	}
+
+	report := r.buildReport(rec)
+	r.submit(report)
+}
+
+func (r *Reporter) buildReport(rec interface{}) Report {
+	stack := string(debug.Stack())
+
+	var buildInfo string
+	if info, ok := debug.ReadBuildInfo(); ok {
+		buildInfo = fmt.Sprintf("%s %s", info.Path, info.Main.Version)
+	}
+
+	var logTail []string
+	if r.logTail != nil {
+		logTail = r.logTail.Lines()
+	}
+
+	return Report{
+		Fingerprint: fingerprint(stack),
+		Panic:       fmt.Sprint(rec),
+		Stack:       stack,
+		BuildInfo:   buildInfo,
+		LogTail:     logTail,
+		OccurredAt:  time.Now(),
+	}
+}
+
+// fingerprint hashes the top frames of the stack trace, so repeated
+// panics from the same bug collapse into one fingerprint regardless of
+// the recovered value's exact text. The "goroutine N [running]:" header
+// and any "created by ..." line are dropped first: both carry a
+// runtime-assigned goroutine ID that differs between occurrences of the
+// exact same panic, which would otherwise defeat deduplication entirely.
+// Everything down to and including the runtime's panic(...) frame is
+// dropped too: those are the recovery path itself (debug.Stack,
+// buildReport, Recover) plus the recovered value's addresses.
+func fingerprint(stack string) string {
+	lines := strings.Split(stack, "\n")
+
+	frames := make([]string, 0, len(lines))
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if strings.HasPrefix(line, "panic(") {
+			frames = frames[:0]
+			i++ // the panic frame's file:line
+			continue
+		}
+		if strings.HasPrefix(line, "goroutine ")|| strings.HasPrefix(line, "created by ") {
+			continue
+		}
+		frames = append(frames, line)
+	}
+
+	if len(frames) > topFrames {
+		frames = frames[:topFrames]
+	}
+	sum := sha256.Sum256([]byte(strings.Join(frames, "\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+func (r *Reporter) rateLimited(fingerprint string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	last, ok := r.seen[fingerprint]
+	now := time.Now()
+	if ok && now.Sub(last) < rateLimitWindow {
+		return true
+	}
+	r.seen[fingerprint] = now
+	return false
+}
+
+func (r *Reporter) submit(report Report) {
+	// Always surface the panic locally, even on a fingerprint that's
+	// already rate-limited for shipping: the rate limit exists to
+	// protect the network receiver from a crash loop, not to hide
+	// repeat panics from whoever's reading this agent's own logs.
+	if r.logger != nil {
+		r.logger.Errorf("panic recovered: %s\n%s", report.Panic, report.Stack)
+	}
+
+	if r.rateLimited(report.Fingerprint) {
+		return
+	}
+
+	if r.url == "" {
+		return
+	}
+
+	if err := r.send(report); err != nil && r.spoolDir != "" {
+		_ = r.spool(report)
+	}
+}
+
+func (r *Reporter) send(report Report) error {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal crash report: %w", err)
+	}
+
+	gzipped, err := compress(data)
+	if err != nil {
+		return fmt.Errorf("failed to compress crash report: %w", err)
+	}
+
+	resp, err := r.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Content-Encoding", "gzip").
+		SetBody(gzipped).
+		Post(r.url)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode() >= 300 {
+		return fmt.Errorf("crash report rejected with status %v", resp.StatusCode())
+	}
+
+	return nil
+}
+
+func compress(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w := gzip.NewWriter(&b)
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func (r *Reporter) spool(report Report) error {
+	if err := os.MkdirAll(r.spoolDir, 0o755); err != nil {
+		return fmt.Errorf("failed to create crash spool dir: %w", err)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return err
+	}
+
+	path := filepath.Join(r.spoolDir, report.Fingerprint+"-"+report.OccurredAt.Format("20060102T150405")+".json")
+	return os.WriteFile(path, data, 0o644)
+}
+
+// RetrySpooled resends any reports left on disk from a previous run, e.g.
+// ones that couldn't be delivered during a network outage, and removes
+// them once delivered.
+func (r *Reporter) RetrySpooled() {
+	if r.spoolDir == "" {
+		return
+	}
+
+	entries, err := os.ReadDir(r.spoolDir)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		path := filepath.Join(r.spoolDir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+
+		var report Report
+		if err := json.Unmarshal(data, &report); err != nil {
+			continue
+		}
+
+		if err := r.send(report); err != nil {
+			continue
+		}
+
+		_ = os.Remove(path)
+	}
+}
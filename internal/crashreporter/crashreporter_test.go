@@ -0,0 +1,94 @@
+package crashreporter
+
+import (
+	"strings"
+	"sync"
+	"testing"
+)
+
+type recordingLogger struct {
+	mu    sync.Mutex
+	lines []string
+}
+
+func (l *recordingLogger) Errorf(template string, args ...interface{}) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.lines = append(l.lines, template)
+}
+
+func TestRecoverLogsLocallyWithNoReportURLConfigured(t *testing.T) {
+	logger := &recordingLogger{}
+	r := New("", "", nil, logger)
+
+	func() {
+		defer r.Recover()
+		panic("boom")
+	}()
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.lines) != 1 || !strings.Contains(logger.lines[0], "panic recovered") {
+		t.Fatalf("expected the panic to be logged locally, got %v", logger.lines)
+	}
+}
+
+func TestRecoverLogsEveryOccurrenceEvenWhenRateLimited(t *testing.T) {
+	logger := &recordingLogger{}
+	r := New("", "", nil, logger)
+
+	panicSameSpot := func() {
+		defer r.Recover()
+		panic("boom")
+	}
+
+	panicSameSpot()
+	panicSameSpot()
+
+	logger.mu.Lock()
+	defer logger.mu.Unlock()
+	if len(logger.lines) != 2 {
+		t.Fatalf("expected both occurrences to be logged locally despite sharing a fingerprint within rateLimitWindow, got %v", logger.lines)
+	}
+}
+
+func TestRateLimitedSuppressesRepeatsWithinWindow(t *testing.T) {
+	r := New("", "", nil, nil)
+
+	if r.rateLimited("fp-1") {
+		t.Fatal("first occurrence of a fingerprint must not be rate-limited")
+	}
+	if !r.rateLimited("fp-1") {
+		t.Fatal("repeat of the same fingerprint within rateLimitWindow must be rate-limited")
+	}
+	if r.rateLimited("fp-2") {
+		t.Fatal("a different fingerprint must not be rate-limited by an unrelated one")
+	}
+}
+
+func TestFingerprintIgnoresGoroutineID(t *testing.T) {
+	stackA := "goroutine 7 [running]:\n" +
+		"internal/agent.(*Agent).RunPool(...)\n" +
+		"\t/src/agent.go:142 +0x65\n" +
+		"created by internal/agent.(*Agent).Run\n" +
+		"\t/src/agent.go:126 +0x18\n"
+
+	stackB := "goroutine 42 [running]:\n" +
+		"internal/agent.(*Agent).RunPool(...)\n" +
+		"\t/src/agent.go:142 +0x65\n" +
+		"created by internal/agent.(*Agent).Run\n" +
+		"\t/src/agent.go:126 +0x18\n"
+
+	if fingerprint(stackA) != fingerprint(stackB) {
+		t.Fatal("expected identical panics on different goroutines to share a fingerprint")
+	}
+}
+
+func TestFingerprintDiffersByFrame(t *testing.T) {
+	stackA := "goroutine 7 [running]:\ninternal/agent.(*Agent).RunPool(...)\n\t/src/agent.go:142\n"
+	stackB := "goroutine 8 [running]:\ninternal/agent.(*Agent).RunReport(...)\n\t/src/agent.go:200\n"
+
+	if fingerprint(stackA) == fingerprint(stackB) {
+		t.Fatal("expected different panics to produce different fingerprints")
+	}
+}
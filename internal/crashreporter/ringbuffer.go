@@ -0,0 +1,49 @@
+package crashreporter
+
+import (
+	"bytes"
+	"sync"
+)
+
+// LogTail is an io.Writer that keeps only the last N lines written to it,
+// so a crash report can carry the log context leading up to the panic
+// without the agent having to hold its whole log history in memory.
+type LogTail struct {
+	mu    sync.Mutex
+	lines []string
+	max   int
+	buf   []byte
+}
+
+func NewLogTail(max int) *LogTail {
+	return &LogTail{max: max}
+}
+
+func (t *LogTail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.buf = append(t.buf, p...)
+	for {
+		i := bytes.IndexByte(t.buf, '\n')
+		if i < 0 {
+			break
+		}
+		t.lines = append(t.lines, string(t.buf[:i]))
+		t.buf = t.buf[i+1:]
+		if len(t.lines) > t.max {
+			t.lines = t.lines[len(t.lines)-t.max:]
+		}
+	}
+
+	return len(p), nil
+}
+
+func (t *LogTail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	out := make([]string, len(t.lines))
+	copy(out, t.lines)
+	return out
+}
@@ -0,0 +1,29 @@
+package crashreporter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogTailKeepsOnlyLastMaxLines(t *testing.T) {
+	tail := NewLogTail(2)
+
+	_, _ = tail.Write([]byte("one\n"))
+	_, _ = tail.Write([]byte("two\n"))
+	_, _ = tail.Write([]byte("three\n"))
+
+	if got, want := tail.Lines(), []string{"two", "three"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Lines() = %v, want %v", got, want)
+	}
+}
+
+func TestLogTailHandlesPartialWrites(t *testing.T) {
+	tail := NewLogTail(5)
+
+	_, _ = tail.Write([]byte("par"))
+	_, _ = tail.Write([]byte("tial\n"))
+
+	if got, want := tail.Lines(), []string{"partial"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Lines() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,81 @@
+// Package grpc mirrors the HTTP /updates/ batch endpoint over gRPC, for
+// agents configured with Config.TransportKind == agent.TransportGRPC.
+package grpc
+
+import (
+	"fmt"
+	"io"
+
+	agentproto "github.com/Osselnet/metrics-collector/internal/agent/proto"
+	"github.com/Osselnet/metrics-collector/internal/storage"
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+)
+
+// MetricsServer implements agentproto.MetricsServiceServer on top of the
+// same storage.Repositories the HTTP handlers use.
+type MetricsServer struct {
+	agentproto.UnimplementedMetricsServiceServer
+	storage storage.Repositories
+	// key mirrors Config.Key on the agent side: when set, every metric
+	// must carry a GaugeHash/CounterHash computed with the same key, or
+	// UpdateBatch rejects the stream. Empty disables verification.
+	key string
+}
+
+func NewMetricsServer(storage storage.Repositories, key string) *MetricsServer {
+	return &MetricsServer{storage: storage, key: key}
+}
+
+func (s *MetricsServer) UpdateBatch(stream agentproto.MetricsService_UpdateBatchServer) error {
+	var accepted int32
+
+	for {
+		m, err := stream.Recv()
+		if err == io.EOF {
+			return stream.SendAndClose(&agentproto.UpdateBatchResponse{Accepted: accepted})
+		}
+		if err != nil {
+			return err
+		}
+
+		if s.key != "" {
+			if err := s.verifyHash(m); err != nil {
+				return err
+			}
+		}
+
+		switch m.Type {
+		case metrics.TypeGauge:
+			if err := s.storage.UpdateGauge(metrics.Name(m.Id), m.Labels, metrics.Gauge(m.Value)); err != nil {
+				return err
+			}
+		case metrics.TypeCounter:
+			if err := s.storage.UpdateCounter(metrics.Name(m.Id), m.Labels, metrics.Counter(m.Delta)); err != nil {
+				return err
+			}
+		}
+
+		accepted++
+	}
+}
+
+// verifyHash recomputes the expected signature for m and rejects it on a
+// mismatch, so a signing key configured on the agent is actually enforced
+// on the gRPC path instead of being silently accepted unchecked.
+func (s *MetricsServer) verifyHash(m *agentproto.Metric) error {
+	var want string
+	switch m.Type {
+	case metrics.TypeGauge:
+		want = metrics.GaugeHash(s.key, m.Id, m.Value, m.Labels)
+	case metrics.TypeCounter:
+		want = metrics.CounterHash(s.key, m.Id, m.Delta, m.Labels)
+	default:
+		return fmt.Errorf("metric %q has unknown type %q", m.Id, m.Type)
+	}
+
+	if m.Hash != want {
+		return fmt.Errorf("metric %q failed hash verification", m.Id)
+	}
+
+	return nil
+}
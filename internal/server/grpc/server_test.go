@@ -0,0 +1,48 @@
+package grpc
+
+import (
+	"testing"
+
+	agentproto "github.com/Osselnet/metrics-collector/internal/agent/proto"
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+)
+
+func TestVerifyHashAcceptsMatchingSignature(t *testing.T) {
+	s := NewMetricsServer(nil, "secret")
+
+	m := &agentproto.Metric{
+		Id:     "PollCount",
+		Type:   metrics.TypeCounter,
+		Delta:  3,
+		Labels: map[string]string{"host": "agent-1"},
+	}
+	m.Hash = metrics.CounterHash("secret", m.Id, m.Delta, m.Labels)
+
+	if err := s.verifyHash(m); err != nil {
+		t.Errorf("verifyHash() error = %v, want nil", err)
+	}
+}
+
+func TestVerifyHashRejectsTamperedValue(t *testing.T) {
+	s := NewMetricsServer(nil, "secret")
+
+	m := &agentproto.Metric{Id: "Alloc", Type: metrics.TypeGauge, Value: 1}
+	m.Hash = metrics.GaugeHash("secret", m.Id, m.Value, nil)
+
+	m.Value = 2 // tampered in transit after signing
+
+	if err := s.verifyHash(m); err == nil {
+		t.Error("verifyHash() = nil, want error for tampered value")
+	}
+}
+
+func TestVerifyHashRejectsWrongKey(t *testing.T) {
+	s := NewMetricsServer(nil, "secret")
+
+	m := &agentproto.Metric{Id: "Alloc", Type: metrics.TypeGauge, Value: 1}
+	m.Hash = metrics.GaugeHash("other-key", m.Id, m.Value, nil)
+
+	if err := s.verifyHash(m); err == nil {
+		t.Error("verifyHash() = nil, want error for mismatched key")
+	}
+}
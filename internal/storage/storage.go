@@ -0,0 +1,75 @@
+// Package storage holds the server-side metric repositories.
+package storage
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+)
+
+// Repositories is the storage-agnostic interface the HTTP and gRPC
+// handlers update through. Every method takes the sample's label set
+// alongside its name, since two instances can legitimately report the
+// same metric name (e.g. PollCount) under different labels.
+type Repositories interface {
+	UpdateGauge(name metrics.Name, labels map[string]string, value metrics.Gauge) error
+	UpdateCounter(name metrics.Name, labels map[string]string, delta metrics.Counter) error
+}
+
+// seriesKey is what a sample is actually keyed by: its name plus its
+// sorted label set, Prometheus-style, so PollCount from two agents no
+// longer collides just because they share a name.
+type seriesKey string
+
+func newSeriesKey(name metrics.Name, labels map[string]string) seriesKey {
+	if len(labels) == 0 {
+		return seriesKey(name)
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, labels[k]))
+	}
+
+	return seriesKey(fmt.Sprintf("%s{%s}", name, strings.Join(parts, ",")))
+}
+
+// MemStorage is an in-memory Repositories implementation keyed by
+// (name, labelset).
+type MemStorage struct {
+	mu       sync.Mutex
+	gauges   map[seriesKey]metrics.Gauge
+	counters map[seriesKey]metrics.Counter
+}
+
+func New() *MemStorage {
+	return &MemStorage{
+		gauges:   make(map[seriesKey]metrics.Gauge),
+		counters: make(map[seriesKey]metrics.Counter),
+	}
+}
+
+func (s *MemStorage) UpdateGauge(name metrics.Name, labels map[string]string, value metrics.Gauge) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.gauges[newSeriesKey(name, labels)] = value
+	return nil
+}
+
+func (s *MemStorage) UpdateCounter(name metrics.Name, labels map[string]string, delta metrics.Counter) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counters[newSeriesKey(name, labels)] += delta
+	return nil
+}
@@ -0,0 +1,25 @@
+package storage
+
+import (
+	"testing"
+
+	"github.com/Osselnet/metrics-collector/pkg/metrics"
+)
+
+func TestUpdateCounterKeyedByLabelSetNotJustName(t *testing.T) {
+	s := New()
+
+	if err := s.UpdateCounter(metrics.PollCount, map[string]string{"host": "agent-1"}, 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.UpdateCounter(metrics.PollCount, map[string]string{"host": "agent-2"}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := s.counters[newSeriesKey(metrics.PollCount, map[string]string{"host": "agent-1"})]; got != 1 {
+		t.Fatalf("agent-1 PollCount = %v, want 1", got)
+	}
+	if got := s.counters[newSeriesKey(metrics.PollCount, map[string]string{"host": "agent-2"})]; got != 1 {
+		t.Fatalf("agent-2 PollCount = %v, want 1", got)
+	}
+}
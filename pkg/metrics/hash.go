@@ -0,0 +1,57 @@
+package metrics
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// sortedLabels renders labels as a deterministic "k1=v1,k2=v2" string so
+// two identical label sets always hash the same way regardless of map
+// iteration order.
+func sortedLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, fmt.Sprintf("%s=%s", k, labels[k]))
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// GaugeHash signs a gauge sample, including its label set, so two agents
+// reporting the same metric name under different labels don't collide.
+func GaugeHash(key, id string, value float64, labels map[string]string) string {
+	if key == "" {
+		return ""
+	}
+
+	data := fmt.Sprintf("%s:gauge:%f:%s", id, value, sortedLabels(labels))
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// CounterHash signs a counter sample, including its label set.
+func CounterHash(key, id string, delta int64, labels map[string]string) string {
+	if key == "" {
+		return ""
+	}
+
+	data := fmt.Sprintf("%s:counter:%d:%s", id, delta, sortedLabels(labels))
+	h := hmac.New(sha256.New, []byte(key))
+	h.Write([]byte(data))
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import "testing"
+
+func TestGaugeHashStableAcrossLabelOrder(t *testing.T) {
+	a := GaugeHash("key", "Alloc", 1.5, map[string]string{"host": "a", "env": "prod"})
+	b := GaugeHash("key", "Alloc", 1.5, map[string]string{"env": "prod", "host": "a"})
+
+	if a != b {
+		t.Fatalf("expected hash to be stable regardless of label map order, got %q and %q", a, b)
+	}
+}
+
+func TestGaugeHashDiffersByLabels(t *testing.T) {
+	a := GaugeHash("key", "PollCount", 1, map[string]string{"host": "agent-1"})
+	b := GaugeHash("key", "PollCount", 1, map[string]string{"host": "agent-2"})
+
+	if a == b {
+		t.Fatal("expected different label sets to produce different hashes")
+	}
+}
+
+func TestCounterHashEmptyKey(t *testing.T) {
+	if got := CounterHash("", "PollCount", 1, nil); got != "" {
+		t.Fatalf("expected empty hash when key is unset, got %q", got)
+	}
+}
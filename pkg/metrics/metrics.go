@@ -0,0 +1,72 @@
+// Package metrics defines the metric value types and the runtime/gopsutil
+// gauge names shared by the agent and the server.
+package metrics
+
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+type Name string
+
+type Gauge float64
+
+type Counter int64
+
+// Metrics is the set of samples collected by one Update/gopsutilUpdate pass.
+type Metrics struct {
+	Gauges   map[Name]Gauge
+	Counters map[Name]Counter
+}
+
+func New() *Metrics {
+	return &Metrics{
+		Gauges:   make(map[Name]Gauge, GaugeLen),
+		Counters: make(map[Name]Counter, CounterLen),
+	}
+}
+
+// runtime.MemStats-derived gauges, plus the agent's own RandomValue and the
+// gopsutil-derived CPU/memory gauges.
+const (
+	Alloc         Name = "Alloc"
+	BuckHashSys   Name = "BuckHashSys"
+	Frees         Name = "Frees"
+	GCCPUFraction Name = "GCCPUFraction"
+	GCSys         Name = "GCSys"
+	HeapAlloc     Name = "HeapAlloc"
+	HeapIdle      Name = "HeapIdle"
+	HeapInuse     Name = "HeapInuse"
+	HeapObjects   Name = "HeapObjects"
+	HeapReleased  Name = "HeapReleased"
+	HeapSys       Name = "HeapSys"
+	LastGC        Name = "LastGC"
+	Lookups       Name = "Lookups"
+	MCacheInuse   Name = "MCacheInuse"
+	MCacheSys     Name = "MCacheSys"
+	MSpanInuse    Name = "MSpanInuse"
+	MSpanSys      Name = "MSpanSys"
+	Mallocs       Name = "Mallocs"
+	NextGC        Name = "NextGC"
+	NumForcedGC   Name = "NumForcedGC"
+	NumGC         Name = "NumGC"
+	OtherSys      Name = "OtherSys"
+	PauseTotalNs  Name = "PauseTotalNs"
+	StackInuse    Name = "StackInuse"
+	StackSys      Name = "StackSys"
+	Sys           Name = "Sys"
+	TotalAlloc    Name = "TotalAlloc"
+	RandomValue   Name = "RandomValue"
+
+	TotalMemory Name = "TotalMemory"
+	FreeMemory  Name = "FreeMemory"
+
+	PollCount Name = "PollCount"
+)
+
+// GaugeLen/CounterLen size the maps built in Agent.Update; they're hints,
+// not hard limits.
+const (
+	GaugeLen   = 28
+	CounterLen = 1
+)